@@ -0,0 +1,211 @@
+package main
+
+import (
+	"fmt"
+	"io/ioutil"
+
+	"github.com/hashicorp/terraform/helper/schema"
+	"github.com/hashicorp/vault/api"
+)
+
+// AuthMethod logs in to Vault with a single already-configured *api.Client
+// and returns the resulting auth secret, from which providerConfigure reads
+// the client token. Implementations should not build their own HTTP client;
+// the provider's *api.Client already carries the address, TLS and
+// namespace settings.
+type AuthMethod interface {
+	Login(client *api.Client, config map[string]interface{}) (*api.Secret, error)
+}
+
+// authMethods is the registry of auth backends the provider's `auth` block
+// can select by name.
+var authMethods = map[string]AuthMethod{
+	"token":      tokenAuthMethod{},
+	"github":     githubAuthMethod{},
+	"approle":    approleAuthMethod{},
+	"kubernetes": kubernetesAuthMethod{},
+	"aws":        awsAuthMethod{},
+	"tls":        tlsAuthMethod{},
+}
+
+// authSchema is the `auth { method = "..."; config = { ... } }` block. It is
+// optional: configs that only set personal_access_token/github_org keep
+// working without it, and a static token still wins if both are absent.
+func authSchema() *schema.Schema {
+	return &schema.Schema{
+		Type:        schema.TypeList,
+		Optional:    true,
+		MaxItems:    1,
+		Description: "Vault auth method to use to obtain a token, as an alternative to a static token or personal_access_token.",
+		Elem: &schema.Resource{
+			Schema: map[string]*schema.Schema{
+				"method": &schema.Schema{
+					Type:        schema.TypeString,
+					Required:    true,
+					Description: "Auth method to use: token, github, approle, kubernetes, aws, or tls.",
+				},
+				"config": &schema.Schema{
+					Type:        schema.TypeMap,
+					Optional:    true,
+					Description: "Method-specific configuration, e.g. role_id/secret_id for approle or role for kubernetes.",
+				},
+			},
+		},
+	}
+}
+
+// tokenAuthMethod is a pass-through for a token supplied in config["token"],
+// so "auth { method = \"token\" }" is interchangeable with the top-level
+// token argument.
+type tokenAuthMethod struct{}
+
+func (tokenAuthMethod) Login(client *api.Client, config map[string]interface{}) (*api.Secret, error) {
+	token, _ := config["token"].(string)
+	if token == "" {
+		return nil, fmt.Errorf("config.token is required for the token auth method")
+	}
+	return &api.Secret{Auth: &api.SecretAuth{ClientToken: token}}, nil
+}
+
+// githubAuthMethod logs in via auth/github/<namespace>/<org>/login using the
+// provider's *api.Client, replacing the hand-rolled HTTP client this logic
+// used to need.
+type githubAuthMethod struct{}
+
+func (githubAuthMethod) Login(client *api.Client, config map[string]interface{}) (*api.Secret, error) {
+	personalAccessToken, _ := config["personal_access_token"].(string)
+	githubOrg, _ := config["github_org"].(string)
+	namespaceDomain, _ := config["namespace_domain"].(string)
+	if personalAccessToken == "" || githubOrg == "" || namespaceDomain == "" {
+		return nil, fmt.Errorf("personal_access_token, github_org and namespace_domain are all required")
+	}
+
+	path := "auth/github/" + namespaceDomain + "/" + githubOrg + "/login"
+	return client.Logical().Write(path, map[string]interface{}{
+		"token": personalAccessToken,
+	})
+}
+
+// approleAuthMethod logs in with role_id/secret_id. If secret_id was
+// delivered as a response-wrapping token (see wrap_ttl on
+// immutability_approle), it is unwrapped first so the caller never has to
+// handle the wrapped/unwrapped distinction itself.
+type approleAuthMethod struct{}
+
+func (approleAuthMethod) Login(client *api.Client, config map[string]interface{}) (*api.Secret, error) {
+	roleID, _ := config["role_id"].(string)
+	secretID, _ := config["secret_id"].(string)
+	wrappedSecretID, _ := config["wrapped_secret_id"].(string)
+	if roleID == "" || (secretID == "" && wrappedSecretID == "") {
+		return nil, fmt.Errorf("config.role_id and either config.secret_id or config.wrapped_secret_id are required for the approle auth method")
+	}
+
+	if wrappedSecretID != "" {
+		unwrapClient, err := client.Clone()
+		if err != nil {
+			return nil, err
+		}
+		unwrapClient.SetToken(wrappedSecretID)
+		unwrapped, err := unwrapClient.Logical().Unwrap("")
+		if err != nil {
+			return nil, fmt.Errorf("error unwrapping secret_id: %s", err)
+		}
+		secretID, _ = unwrapped.Data["secret_id"].(string)
+		if secretID == "" {
+			return nil, fmt.Errorf("unwrapped response did not contain a secret_id")
+		}
+	}
+
+	mount, _ := config["mount"].(string)
+	if mount == "" {
+		mount = "approle"
+	}
+
+	return client.Logical().Write("auth/"+mount+"/login", map[string]interface{}{
+		"role_id":   roleID,
+		"secret_id": secretID,
+	})
+}
+
+// kubernetesAuthMethod logs in using the JWT Kubernetes mounts into the pod
+// for its service account.
+type kubernetesAuthMethod struct{}
+
+const kubernetesServiceAccountTokenPath = "/var/run/secrets/kubernetes.io/serviceaccount/token"
+
+func (kubernetesAuthMethod) Login(client *api.Client, config map[string]interface{}) (*api.Secret, error) {
+	role, _ := config["role"].(string)
+	if role == "" {
+		return nil, fmt.Errorf("config.role is required for the kubernetes auth method")
+	}
+
+	jwtPath, _ := config["jwt_path"].(string)
+	if jwtPath == "" {
+		jwtPath = kubernetesServiceAccountTokenPath
+	}
+	jwt, err := ioutil.ReadFile(jwtPath)
+	if err != nil {
+		return nil, fmt.Errorf("error reading service account JWT from %s: %s", jwtPath, err)
+	}
+
+	mount, _ := config["mount"].(string)
+	if mount == "" {
+		mount = "kubernetes"
+	}
+
+	return client.Logical().Write("auth/"+mount+"/login", map[string]interface{}{
+		"role": role,
+		"jwt":  string(jwt),
+	})
+}
+
+// awsAuthMethod logs in with the iam_http_request_* fields Vault's aws auth
+// method expects from a pre-signed STS GetCallerIdentity request. Producing
+// that signature is left to the caller so this provider does not need to
+// take on an AWS SDK dependency just to authenticate.
+type awsAuthMethod struct{}
+
+func (awsAuthMethod) Login(client *api.Client, config map[string]interface{}) (*api.Secret, error) {
+	requestURL, _ := config["iam_http_request_url"].(string)
+	requestBody, _ := config["iam_request_body"].(string)
+	requestHeaders, _ := config["iam_request_headers"].(string)
+	if requestURL == "" || requestBody == "" || requestHeaders == "" {
+		return nil, fmt.Errorf("config.iam_http_request_url, iam_request_body and iam_request_headers are required for the aws auth method")
+	}
+
+	mount, _ := config["mount"].(string)
+	if mount == "" {
+		mount = "aws"
+	}
+
+	data := map[string]interface{}{
+		"iam_http_request_method": "POST",
+		"iam_http_request_url":    requestURL,
+		"iam_request_body":        requestBody,
+		"iam_request_headers":     requestHeaders,
+	}
+	if role, _ := config["role"].(string); role != "" {
+		data["role"] = role
+	}
+
+	return client.Logical().Write("auth/"+mount+"/login", data)
+}
+
+// tlsAuthMethod logs in using the client certificate already configured on
+// the *api.Client via the provider's client_auth block, so there is nothing
+// left to present here beyond the mount and optional cert role name.
+type tlsAuthMethod struct{}
+
+func (tlsAuthMethod) Login(client *api.Client, config map[string]interface{}) (*api.Secret, error) {
+	mount, _ := config["mount"].(string)
+	if mount == "" {
+		mount = "cert"
+	}
+
+	data := map[string]interface{}{}
+	if name, _ := config["name"].(string); name != "" {
+		data["name"] = name
+	}
+
+	return client.Logical().Write("auth/"+mount+"/login", data)
+}
@@ -0,0 +1,65 @@
+package main
+
+import (
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"fmt"
+)
+
+// generateCSR generates a private key locally and a PEM-encoded CSR for
+// it, so pkiWrite can have Vault sign the CSR instead of generating the
+// key itself. The private key never leaves this function's caller.
+func generateCSR(commonName, keyType string, keyBits int) (csrPEM string, privateKeyPEM string, err error) {
+	var signer crypto.Signer
+	var keyBlock *pem.Block
+
+	switch keyType {
+	case "", "rsa":
+		if keyBits == 0 {
+			keyBits = 2048
+		}
+		key, err := rsa.GenerateKey(rand.Reader, keyBits)
+		if err != nil {
+			return "", "", fmt.Errorf("error generating RSA key: %s", err)
+		}
+		signer = key
+		keyBlock = &pem.Block{Type: "RSA PRIVATE KEY", Bytes: x509.MarshalPKCS1PrivateKey(key)}
+
+	case "ec":
+		curve := elliptic.P256()
+		if keyBits >= 384 {
+			curve = elliptic.P384()
+		}
+		key, err := ecdsa.GenerateKey(curve, rand.Reader)
+		if err != nil {
+			return "", "", fmt.Errorf("error generating EC key: %s", err)
+		}
+		ecBytes, err := x509.MarshalECPrivateKey(key)
+		if err != nil {
+			return "", "", fmt.Errorf("error marshaling EC key: %s", err)
+		}
+		signer = key
+		keyBlock = &pem.Block{Type: "EC PRIVATE KEY", Bytes: ecBytes}
+
+	default:
+		return "", "", fmt.Errorf("unsupported key_type %q, expected \"rsa\" or \"ec\"", keyType)
+	}
+
+	template := &x509.CertificateRequest{
+		Subject: pkix.Name{CommonName: commonName},
+	}
+	csrDER, err := x509.CreateCertificateRequest(rand.Reader, template, signer)
+	if err != nil {
+		return "", "", fmt.Errorf("error creating CSR: %s", err)
+	}
+
+	csrPEM = string(pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE REQUEST", Bytes: csrDER}))
+	privateKeyPEM = string(pem.EncodeToMemory(keyBlock))
+	return csrPEM, privateKeyPEM, nil
+}
@@ -0,0 +1,64 @@
+package main
+
+import (
+	"crypto/x509"
+	"encoding/pem"
+	"testing"
+)
+
+func TestGenerateCSRRSA(t *testing.T) {
+	csrPEM, keyPEM, err := generateCSR("example.com", "rsa", 2048)
+	if err != nil {
+		t.Fatalf("generateCSR: %s", err)
+	}
+
+	block, _ := pem.Decode([]byte(csrPEM))
+	if block == nil || block.Type != "CERTIFICATE REQUEST" {
+		t.Fatalf("csrPEM did not decode to a CERTIFICATE REQUEST block")
+	}
+	csr, err := x509.ParseCertificateRequest(block.Bytes)
+	if err != nil {
+		t.Fatalf("ParseCertificateRequest: %s", err)
+	}
+	if csr.Subject.CommonName != "example.com" {
+		t.Fatalf("CommonName = %q, want %q", csr.Subject.CommonName, "example.com")
+	}
+	if err := csr.CheckSignature(); err != nil {
+		t.Fatalf("CSR signature did not verify: %s", err)
+	}
+
+	keyBlock, _ := pem.Decode([]byte(keyPEM))
+	if keyBlock == nil || keyBlock.Type != "RSA PRIVATE KEY" {
+		t.Fatalf("privateKeyPEM did not decode to an RSA PRIVATE KEY block")
+	}
+}
+
+func TestGenerateCSREC(t *testing.T) {
+	csrPEM, keyPEM, err := generateCSR("example.com", "ec", 256)
+	if err != nil {
+		t.Fatalf("generateCSR: %s", err)
+	}
+
+	block, _ := pem.Decode([]byte(csrPEM))
+	if block == nil {
+		t.Fatalf("csrPEM did not decode")
+	}
+	csr, err := x509.ParseCertificateRequest(block.Bytes)
+	if err != nil {
+		t.Fatalf("ParseCertificateRequest: %s", err)
+	}
+	if err := csr.CheckSignature(); err != nil {
+		t.Fatalf("CSR signature did not verify: %s", err)
+	}
+
+	keyBlock, _ := pem.Decode([]byte(keyPEM))
+	if keyBlock == nil || keyBlock.Type != "EC PRIVATE KEY" {
+		t.Fatalf("privateKeyPEM did not decode to an EC PRIVATE KEY block")
+	}
+}
+
+func TestGenerateCSRUnsupportedKeyType(t *testing.T) {
+	if _, _, err := generateCSR("example.com", "dsa", 0); err == nil {
+		t.Fatal("expected an error for an unsupported key_type")
+	}
+}
@@ -0,0 +1,104 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+
+	"github.com/hashicorp/terraform/helper/schema"
+	"github.com/hashicorp/vault/api"
+)
+
+func kv2SecretDataSource() *schema.Resource {
+	return &schema.Resource{
+		Read: kv2SecretDataSourceRead,
+
+		Schema: map[string]*schema.Schema{
+			"path": &schema.Schema{
+				Type:        schema.TypeString,
+				Required:    true,
+				Description: "Full logical path of the secret, e.g. \"secret/myapp/config\"",
+			},
+			"version": &schema.Schema{
+				Type:        schema.TypeInt,
+				Optional:    true,
+				Description: "Specific version to read. Defaults to the current version.",
+			},
+			"data_json": &schema.Schema{
+				Type:        schema.TypeString,
+				Computed:    true,
+				Description: "JSON-encoded secret data read from Vault.",
+			},
+			"data": &schema.Schema{
+				Type:        schema.TypeMap,
+				Computed:    true,
+				Description: "Map of strings read from Vault.",
+			},
+			"created_time": &schema.Schema{
+				Type:        schema.TypeString,
+				Computed:    true,
+				Description: "Creation time of the version read",
+			},
+			"deleted_time": &schema.Schema{
+				Type:        schema.TypeString,
+				Computed:    true,
+				Description: "Deletion time of the version read, if it has been soft-deleted",
+			},
+			"destroyed": &schema.Schema{
+				Type:        schema.TypeBool,
+				Computed:    true,
+				Description: "True if the version read has been permanently destroyed",
+			},
+			"custom_metadata": &schema.Schema{
+				Type:        schema.TypeMap,
+				Computed:    true,
+				Description: "Custom metadata attached to the secret",
+			},
+		},
+	}
+}
+
+func kv2SecretDataSourceRead(d *schema.ResourceData, meta interface{}) error {
+	context := meta.(*ResourceContext)
+	client := *context.client
+
+	path := d.Get("path").(string)
+	mount, err := resolveKV2Mount(&client, path)
+	if err != nil {
+		return err
+	}
+	if !mount.IsV2() {
+		return fmt.Errorf("%s is mounted as a KV version %s engine; immutability_kv2_secret requires KV v2 (use immutability_secret instead)", path, mount.Version)
+	}
+	key := mount.Key(path)
+
+	var secret *api.Secret
+	if version, ok := d.GetOk("version"); ok {
+		secret, err = client.Logical().ReadWithData(mount.dataPath(key), map[string][]string{
+			"version": {fmt.Sprintf("%d", version.(int))},
+		})
+	} else {
+		secret, err = client.Logical().Read(mount.dataPath(key))
+	}
+	if err != nil {
+		return fmt.Errorf("error reading %s from Vault: %s", path, err)
+	}
+	if secret == nil || secret.Data["data"] == nil {
+		return fmt.Errorf("%s has no current version in Vault", path)
+	}
+
+	log.Printf("[DEBUG] Read KV v2 secret from %s", mount.dataPath(key))
+	d.SetId(secret.RequestID)
+
+	data, _ := secret.Data["data"].(map[string]interface{})
+
+	jsonDataBytes, _ := json.Marshal(data)
+	d.Set("data_json", string(jsonDataBytes))
+	d.Set("data", kv2StringData(data))
+
+	if metadata, ok := secret.Data["metadata"].(map[string]interface{}); ok {
+		kv2SetComputedFromMetadata(d, metadata)
+	}
+
+	return nil
+}
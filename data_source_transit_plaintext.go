@@ -0,0 +1,55 @@
+package main
+
+import (
+	"fmt"
+
+	"github.com/hashicorp/terraform/helper/schema"
+)
+
+// transitPlaintextDataSource decrypts a value that was encrypted under the
+// provider's transit block. secret_id, certificate, private_key and
+// private_key_pem become Transit ciphertext once a transit block is
+// configured (generic and KV v2 secret data are not encrypted), so any
+// config that needs the real value - not just
+// a value to store at rest - reads it back through here instead of
+// interpolating the computed field directly.
+func transitPlaintextDataSource() *schema.Resource {
+	return &schema.Resource{
+		Read: transitPlaintextDataSourceRead,
+
+		Schema: map[string]*schema.Schema{
+			"ciphertext": &schema.Schema{
+				Type:        schema.TypeString,
+				Required:    true,
+				Description: "Transit ciphertext to decrypt, as produced by a computed field when the provider's transit block is configured.",
+			},
+			"context": &schema.Schema{
+				Type:        schema.TypeString,
+				Optional:    true,
+				Description: "Context the value was encrypted with, matching the resource's transit_context.",
+			},
+			"plaintext": &schema.Schema{
+				Type:        schema.TypeString,
+				Computed:    true,
+				Description: "Decrypted value.",
+			},
+		},
+	}
+}
+
+func transitPlaintextDataSourceRead(d *schema.ResourceData, meta interface{}) error {
+	context := meta.(*ResourceContext)
+	if context.transit == nil {
+		return fmt.Errorf("immutability_transit_plaintext requires the provider's transit block to be configured")
+	}
+
+	ciphertext := d.Get("ciphertext").(string)
+	plaintext, err := context.transit.Decrypt(ciphertext, d.Get("context").(string))
+	if err != nil {
+		return fmt.Errorf("error decrypting ciphertext: %s", err)
+	}
+
+	d.SetId(ciphertext)
+	d.Set("plaintext", plaintext)
+	return nil
+}
@@ -0,0 +1,108 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+
+	"github.com/hashicorp/terraform/helper/schema"
+	"github.com/hashicorp/vault/api"
+)
+
+// kv2Mount describes the Vault mount backing a logical secret path, as
+// resolved from sys/internal/ui/mounts so callers never have to be told
+// the KV version up front.
+type kv2Mount struct {
+	// Path is the mount point, without a trailing slash, e.g. "secret".
+	Path    string
+	Version string
+}
+
+func (m *kv2Mount) IsV2() bool {
+	return m.Version == "2"
+}
+
+// Key strips the mount point off of a full logical path, e.g.
+// "secret/myapp/config" under mount "secret" becomes "myapp/config".
+func (m *kv2Mount) Key(path string) string {
+	return strings.TrimPrefix(path, m.Path+"/")
+}
+
+// dataPath is the path KV v2 reads and writes secret data through.
+func (m *kv2Mount) dataPath(key string) string {
+	return m.Path + "/data/" + key
+}
+
+// metadataPath is the path KV v2 exposes version metadata, delete and
+// undelete through.
+func (m *kv2Mount) metadataPath(key string) string {
+	return m.Path + "/metadata/" + key
+}
+
+// resolveKV2Mount looks up the mount backing path via
+// sys/internal/ui/mounts/<path> so the resource/data source can auto-detect
+// whether it is actually talking to a KV v2 engine.
+func resolveKV2Mount(client *api.Client, path string) (*kv2Mount, error) {
+	secret, err := client.Logical().Read("sys/internal/ui/mounts/" + path)
+	if err != nil {
+		return nil, fmt.Errorf("error looking up mount for %s: %s", path, err)
+	}
+	if secret == nil {
+		return nil, fmt.Errorf("no mount found for %s", path)
+	}
+
+	mountPath, _ := secret.Data["path"].(string)
+	if mountPath == "" {
+		return nil, fmt.Errorf("Vault did not return a mount path for %s", path)
+	}
+
+	version := "1"
+	if options, ok := secret.Data["options"].(map[string]interface{}); ok {
+		if v, ok := options["version"].(string); ok && v != "" {
+			version = v
+		}
+	}
+
+	return &kv2Mount{
+		Path:    strings.TrimSuffix(mountPath, "/"),
+		Version: version,
+	}, nil
+}
+
+// kv2SetComputedFromMetadata sets the version/created_time/deleted_time/
+// destroyed/custom_metadata computed fields shared by the
+// immutability_kv2_secret resource and data source from a KV v2 metadata
+// map, however it was nested in the response that produced it.
+func kv2SetComputedFromMetadata(d *schema.ResourceData, metadata map[string]interface{}) {
+	if v, ok := metadata["version"]; ok {
+		d.Set("version", v)
+	}
+	if v, ok := metadata["created_time"]; ok {
+		d.Set("created_time", v)
+	}
+	if v, ok := metadata["deleted_time"]; ok {
+		d.Set("deleted_time", v)
+	}
+	if v, ok := metadata["destroyed"]; ok {
+		d.Set("destroyed", v)
+	}
+	if v, ok := metadata["custom_metadata"]; ok {
+		d.Set("custom_metadata", v)
+	}
+}
+
+// kv2StringData flattens a KV v2 data map into the string-only map our
+// "data" schema field expects, matching genericSecretDataSourceRead's
+// handling of non-string values.
+func kv2StringData(data map[string]interface{}) map[string]string {
+	dataMap := map[string]string{}
+	for k, v := range data {
+		if vs, ok := v.(string); ok {
+			dataMap[k] = vs
+		} else {
+			vBytes, _ := json.Marshal(v)
+			dataMap[k] = string(vBytes)
+		}
+	}
+	return dataMap
+}
@@ -0,0 +1,43 @@
+package main
+
+import "testing"
+
+func TestKV2MountKey(t *testing.T) {
+	m := &kv2Mount{Path: "secret", Version: "2"}
+	if got, want := m.Key("secret/myapp/config"), "myapp/config"; got != want {
+		t.Fatalf("Key() = %q, want %q", got, want)
+	}
+}
+
+func TestKV2MountDataAndMetadataPath(t *testing.T) {
+	m := &kv2Mount{Path: "secret", Version: "2"}
+	if got, want := m.dataPath("myapp/config"), "secret/data/myapp/config"; got != want {
+		t.Fatalf("dataPath() = %q, want %q", got, want)
+	}
+	if got, want := m.metadataPath("myapp/config"), "secret/metadata/myapp/config"; got != want {
+		t.Fatalf("metadataPath() = %q, want %q", got, want)
+	}
+}
+
+func TestKV2MountIsV2(t *testing.T) {
+	if !(&kv2Mount{Version: "2"}).IsV2() {
+		t.Fatal("IsV2() = false for version 2")
+	}
+	if (&kv2Mount{Version: "1"}).IsV2() {
+		t.Fatal("IsV2() = true for version 1")
+	}
+}
+
+func TestKV2StringData(t *testing.T) {
+	data := map[string]interface{}{
+		"plain":  "value",
+		"number": float64(42),
+	}
+	got := kv2StringData(data)
+	if got["plain"] != "value" {
+		t.Fatalf("plain = %q, want %q", got["plain"], "value")
+	}
+	if got["number"] != "42" {
+		t.Fatalf("number = %q, want %q", got["number"], "42")
+	}
+}
@@ -1,16 +1,8 @@
 package main
 
 import (
-	"bytes"
-	"crypto/tls"
-	"crypto/x509"
-	"encoding/json"
-	"errors"
 	"fmt"
-	"io/ioutil"
 	"log"
-	"net/http"
-	"time"
 
 	"github.com/hashicorp/terraform/helper/schema"
 	"github.com/hashicorp/terraform/terraform"
@@ -21,10 +13,29 @@ type ResourceContext struct {
 	client          *api.Client
 	githubOrg       string
 	namespaceDomain string
+	renewers        *RenewerManager
+	transit         *TransitConfig
+}
+
+// teardownProvider wraps *schema.Provider so that the RenewerManager
+// created in providerConfigure can be stopped when Terraform calls
+// Stop() on the provider, rather than leaking renewal goroutines past
+// the end of a run.
+type teardownProvider struct {
+	*schema.Provider
+	context *ResourceContext
+}
+
+func (p *teardownProvider) Stop() error {
+	if p.context != nil && p.context.renewers != nil {
+		p.context.renewers.StopAll()
+	}
+	return p.Provider.Stop()
 }
 
 func Provider() terraform.ResourceProvider {
-	return &schema.Provider{
+	provider := &teardownProvider{}
+	provider.Provider = &schema.Provider{
 		Schema: map[string]*schema.Schema{
 			"address": &schema.Schema{
 				Type:        schema.TypeString,
@@ -93,6 +104,36 @@ func Provider() terraform.ResourceProvider {
 				DefaultFunc: schema.EnvDefaultFunc("VAULT_SKIP_VERIFY", ""),
 				Description: "Set this to true only if the target Vault server is an insecure development instance.",
 			},
+			"auth": authSchema(),
+			"transit": &schema.Schema{
+				Type:     schema.TypeList,
+				Optional: true,
+				MaxItems: 1,
+				Description: "Encrypt computed secret fields (secret_id on immutability_approle; certificate, private_key and private_key_pem on " +
+					"immutability_ssl) with Vault Transit before they are written to Terraform state. Once set, those fields contain Transit " +
+					"ciphertext, not the plaintext secret - use the immutability_transit_plaintext data source to recover the real value in " +
+					"configs that need it. Data read by the immutability_secret and immutability_kv2_secret data sources is not encrypted.",
+				Elem: &schema.Resource{
+					Schema: map[string]*schema.Schema{
+						"mount": &schema.Schema{
+							Type:        schema.TypeString,
+							Optional:    true,
+							Default:     "transit",
+							Description: "Mount path of the transit secrets engine.",
+						},
+						"key": &schema.Schema{
+							Type:        schema.TypeString,
+							Required:    true,
+							Description: "Name of the transit key used to encrypt and decrypt computed fields.",
+						},
+						"datakey": &schema.Schema{
+							Type:        schema.TypeBool,
+							Optional:    true,
+							Description: "Use envelope encryption (datakey/plaintext) instead of a direct encrypt/decrypt call, for large payloads.",
+						},
+					},
+				},
+			},
 			"max_lease_ttl_seconds": &schema.Schema{
 				Type:     schema.TypeInt,
 				Optional: true,
@@ -107,81 +148,30 @@ func Provider() terraform.ResourceProvider {
 			},
 		},
 
-		ConfigureFunc: providerConfigure,
+		ConfigureFunc: func(d *schema.ResourceData) (interface{}, error) {
+			meta, err := providerConfigure(d)
+			if err != nil {
+				return nil, err
+			}
+			provider.context = meta.(*ResourceContext)
+			return meta, nil
+		},
 
 		DataSourcesMap: map[string]*schema.Resource{
-			"immutability_secret": genericSecretDataSource(),
+			"immutability_secret":            genericSecretDataSource(),
+			"immutability_kv2_secret":        kv2SecretDataSource(),
+			"immutability_transit_plaintext": transitPlaintextDataSource(),
 		},
 
 		ResourcesMap: map[string]*schema.Resource{
-			"immutability_secret":  genericSecretResource(),
-			"immutability_policy":  policyResource(),
-			"immutability_ssl":     pkiResource(),
-			"immutability_approle": approleResource(),
+			"immutability_secret":     genericSecretResource(),
+			"immutability_policy":     policyResource(),
+			"immutability_ssl":        pkiResource(),
+			"immutability_approle":    approleResource(),
+			"immutability_kv2_secret": kv2SecretResource(),
 		},
 	}
-}
-
-func githubLogin(d *schema.ResourceData) (string, error) {
-	address := d.Get("address").(string)
-	githubOrg := d.Get("github_org").(string)
-	namespaceDomain := d.Get("namespace_domain").(string)
-
-	personalAccessToken := d.Get("personal_access_token").(string)
-	if personalAccessToken == "" || githubOrg == "" || namespaceDomain == "" {
-		return "", errors.New("Missing personal_access_token or github_org or namespace_domain")
-	}
-
-	githubPath := "github/" + namespaceDomain + "/" + githubOrg
-	client := &http.Client{
-		Timeout: time.Second * 10,
-	}
-	vaultCaCertFile := d.Get("ca_cert_file").(string)
-	log.Printf("[DEBUG] CACert File %s", vaultCaCertFile)
-	if vaultCaCertFile != "" {
-		caCert, err := ioutil.ReadFile(vaultCaCertFile)
-		if err != nil {
-			return "", err
-		}
-		caCertPool := x509.NewCertPool()
-		caCertPool.AppendCertsFromPEM(caCert)
-		client = &http.Client{
-			Transport: &http.Transport{
-				TLSClientConfig: &tls.Config{
-					RootCAs: caCertPool,
-				},
-			},
-		}
-	}
-	vaultGitHubURL := address + "/v1/auth/" + githubPath + "/login"
-	log.Printf("[DEBUG] GitHub Login URL %s", vaultGitHubURL)
-	var jsonStr = []byte(`{"token":"` + personalAccessToken + `"}`)
-	authRequest, _ := http.NewRequest("POST", vaultGitHubURL, bytes.NewBuffer(jsonStr))
-	resp, err := client.Do(authRequest)
-	if err != nil {
-		return "", err
-	}
-	if resp == nil {
-		return "", fmt.Errorf("No response from vault during approle auth")
-	}
-
-	if resp.StatusCode != 200 {
-		return "", fmt.Errorf("Vault authentication to GitHub Status %d", resp.StatusCode)
-	}
-	var payload api.Secret
-	var htmlData []byte
-	if resp != nil {
-		htmlData, _ = ioutil.ReadAll(resp.Body)
-	}
-	if err != nil {
-		return "", err
-	}
-	err = json.Unmarshal(htmlData, &payload)
-	if err != nil {
-		return "", err
-	}
-	return payload.Auth.ClientToken, nil
-
+	return provider
 }
 
 func providerConfigure(d *schema.ResourceData) (interface{}, error) {
@@ -222,22 +212,85 @@ func providerConfigure(d *schema.ResourceData) (interface{}, error) {
 	token := d.Get("token").(string)
 	personalAccessToken := d.Get("personal_access_token").(string)
 
-	if personalAccessToken != "" {
+	var authSecret *api.Secret
+	switch authI := d.Get("auth").([]interface{}); {
+	case len(authI) == 1:
+		authBlock := authI[0].(map[string]interface{})
+		method := authBlock["method"].(string)
+		methodConfig, _ := authBlock["config"].(map[string]interface{})
+
+		impl, ok := authMethods[method]
+		if !ok {
+			return nil, fmt.Errorf("unknown auth method %q", method)
+		}
+		log.Printf("[DEBUG] Using %s auth method", method)
+		authSecret, err = impl.Login(client, methodConfig)
+		if err != nil {
+			log.Printf("[ERROR] %s Login Failed", method)
+			return nil, fmt.Errorf("%s auth failed: %s", method, err)
+		}
+	case personalAccessToken != "":
+		// Preserve the historical personal_access_token/github_org fields
+		// by routing them through the github AuthMethod, rather than
+		// requiring every existing config to grow an auth block.
 		log.Println("[DEBUG] Using GitHub Login")
-		token, err = githubLogin(d)
+		authSecret, err = githubAuthMethod{}.Login(client, map[string]interface{}{
+			"personal_access_token": personalAccessToken,
+			"github_org":            d.Get("github_org").(string),
+			"namespace_domain":      d.Get("namespace_domain").(string),
+		})
 		if err != nil {
 			log.Println("[ERROR] GitHub Login Failed")
 			return nil, err
 		}
+	}
 
+	if authSecret != nil {
+		if authSecret.Auth == nil || authSecret.Auth.ClientToken == "" {
+			return nil, fmt.Errorf("auth method returned no token")
+		}
+		token = authSecret.Auth.ClientToken
 	}
 	if token == "" {
 		return nil, fmt.Errorf("No authentication token was supplied!")
 	}
 	client.SetToken(token)
+
+	transitI := d.Get("transit").([]interface{})
+	if len(transitI) > 1 {
+		return nil, fmt.Errorf("transit block may appear only once")
+	}
+
 	var context ResourceContext
 	context.client = client
 	context.githubOrg = d.Get("github_org").(string)
 	context.namespaceDomain = d.Get("namespace_domain").(string)
+	context.renewers = NewRenewerManager()
+
+	if len(transitI) == 1 {
+		transitBlock := transitI[0].(map[string]interface{})
+		context.transit = &TransitConfig{
+			Client:  client,
+			Mount:   transitBlock["mount"].(string),
+			Key:     transitBlock["key"].(string),
+			Datakey: transitBlock["datakey"].(bool),
+		}
+	}
+
+	if authSecret != nil && authSecret.Auth.Renewable {
+		err = context.renewers.Watch(RenewerConfig{
+			ID:     "provider-token",
+			Client: client,
+			Secret: authSecret,
+			OnRenew: func(renewed *api.Secret) {
+				log.Printf("[DEBUG] Renewed provider auth token")
+				client.SetToken(renewed.Auth.ClientToken)
+			},
+		})
+		if err != nil {
+			return nil, fmt.Errorf("error starting provider token renewer: %s", err)
+		}
+	}
+
 	return &context, nil
 }
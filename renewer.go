@@ -0,0 +1,171 @@
+package main
+
+import (
+	"log"
+	"sync"
+
+	"github.com/hashicorp/vault/api"
+)
+
+// RenewerManager owns every lease renewer started by resources in this
+// provider instance, so they can all be stopped cleanly when the provider
+// is torn down instead of leaking goroutines past the end of a run.
+type RenewerManager struct {
+	mu       sync.Mutex
+	renewers map[string]*api.Renewer
+	errs     map[string]error
+	values   map[string]string
+}
+
+func NewRenewerManager() *RenewerManager {
+	return &RenewerManager{
+		renewers: make(map[string]*api.Renewer),
+		errs:     make(map[string]error),
+		values:   make(map[string]string),
+	}
+}
+
+// SetError records a renewal failure for id so a later Read can surface
+// it. Resources with stop_on_error set do this from their OnError callback.
+func (m *RenewerManager) SetError(id string, err error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.errs[id] = err
+}
+
+// TakeError returns and clears the renewal failure recorded for id, if any.
+func (m *RenewerManager) TakeError(id string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	err := m.errs[id]
+	delete(m.errs, id)
+	return err
+}
+
+// SetValue records the latest value Vault issued for id on renewal (e.g. a
+// rotated secret_id or certificate). The ResourceData passed to Create is
+// gone by the time a renewal fires in the background, so OnRenew callbacks
+// stash the rotated value here instead of calling d.Set directly; Read
+// picks it up on the next refresh via TakeValue.
+func (m *RenewerManager) SetValue(id, value string) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.values[id] = value
+}
+
+// TakeValue returns and clears the value recorded for id via SetValue, if
+// any. It must clear on read: encryption under transit is non-deterministic,
+// so leaving the cache populated would make Read re-encrypt the same
+// plaintext into a fresh ciphertext on every subsequent refresh even though
+// nothing rotated, and the computed attribute would never stabilize.
+func (m *RenewerManager) TakeValue(id string) (string, bool) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	value, ok := m.values[id]
+	delete(m.values, id)
+	return value, ok
+}
+
+// RenewerConfig describes how a single resource wants its lease watched.
+type RenewerConfig struct {
+	// ID identifies this renewer within the manager, typically the
+	// resource ID so a later Stop(id) can find it again.
+	ID string
+
+	Client *api.Client
+	Secret *api.Secret
+
+	// Increment is the renew_increment argument in seconds, or 0 to let
+	// Vault pick its default increment.
+	Increment int
+
+	// OnRenew is invoked with the rotated secret every time Vault issues
+	// a new lease. It runs long after Create's ResourceData is gone, so
+	// it should record the rotated value with SetValue rather than call
+	// d.Set - the caller's Read picks the value back up from there.
+	OnRenew func(*api.Secret)
+
+	// OnError is invoked if the watcher exits with an error. Whether that
+	// should taint the resource is up to the caller (see stop_on_error).
+	OnError func(error)
+}
+
+// Watch starts a LifetimeWatcher for cfg.Secret and tracks it under cfg.ID.
+// It is a no-op if the secret is not renewable.
+func (m *RenewerManager) Watch(cfg RenewerConfig) error {
+	if cfg.Secret == nil {
+		return nil
+	}
+	renewable := cfg.Secret.Renewable
+	if cfg.Secret.Auth != nil {
+		renewable = cfg.Secret.Auth.Renewable
+	}
+	if !renewable {
+		return nil
+	}
+
+	renewer, err := cfg.Client.NewRenewer(&api.RenewerInput{
+		Secret:    cfg.Secret,
+		Increment: cfg.Increment,
+	})
+	if err != nil {
+		return err
+	}
+
+	m.mu.Lock()
+	if existing, ok := m.renewers[cfg.ID]; ok {
+		existing.Stop()
+	}
+	m.renewers[cfg.ID] = renewer
+	m.mu.Unlock()
+
+	go renewer.Renew()
+	go func() {
+		for {
+			select {
+			case err := <-renewer.DoneCh():
+				if err != nil {
+					log.Printf("[ERROR] Renewer for %s exited: %s", cfg.ID, err)
+					if cfg.OnError != nil {
+						cfg.OnError(err)
+					}
+				}
+				return
+			case renewal, ok := <-renewer.RenewCh():
+				if !ok {
+					return
+				}
+				log.Printf("[DEBUG] Renewed lease for %s", cfg.ID)
+				if cfg.OnRenew != nil {
+					cfg.OnRenew(renewal.Secret)
+				}
+			}
+		}
+	}()
+
+	return nil
+}
+
+// Stop stops and forgets the renewer tracked under id, if any. Resources
+// call this from Delete so a destroyed resource doesn't keep renewing.
+func (m *RenewerManager) Stop(id string) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if renewer, ok := m.renewers[id]; ok {
+		renewer.Stop()
+		delete(m.renewers, id)
+	}
+	delete(m.values, id)
+}
+
+// StopAll stops every renewer this manager owns. The provider calls this
+// during teardown so no renewal goroutine outlives the Terraform run.
+func (m *RenewerManager) StopAll() {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	for id, renewer := range m.renewers {
+		renewer.Stop()
+		delete(m.renewers, id)
+		delete(m.values, id)
+	}
+}
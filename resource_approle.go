@@ -4,8 +4,10 @@ import (
 	"errors"
 	"fmt"
 	"log"
+	"time"
 
 	"github.com/hashicorp/terraform/helper/schema"
+	"github.com/hashicorp/vault/api"
 )
 
 func approleResource() *schema.Resource {
@@ -36,6 +38,70 @@ func approleResource() *schema.Resource {
 				Computed:    true,
 				Description: "AppRole Login path",
 			},
+			"auto_renew": &schema.Schema{
+				Type:        schema.TypeBool,
+				Optional:    true,
+				Default:     false,
+				Description: "Automatically renew the SecretID lease in the background for as long as the resource exists",
+			},
+			"renew_increment": &schema.Schema{
+				Type:        schema.TypeInt,
+				Optional:    true,
+				Description: "Requested number of seconds to extend the lease by on each renewal, passed to Vault as a hint",
+			},
+			"stop_on_error": &schema.Schema{
+				Type:        schema.TypeBool,
+				Optional:    true,
+				Default:     false,
+				Description: "If the background renewal fails, taint this resource instead of letting the lease silently expire",
+			},
+			"lease_renewable": &schema.Schema{
+				Type:        schema.TypeBool,
+				Computed:    true,
+				Description: "True if the SecretID lease returned by Vault can be renewed",
+			},
+			"lease_start_time": &schema.Schema{
+				Type:        schema.TypeString,
+				Computed:    true,
+				Description: "Time at which the lease was issued, using the clock of the system where Terraform was running",
+			},
+			"lease_max_ttl": &schema.Schema{
+				Type:        schema.TypeInt,
+				Computed:    true,
+				Description: "Lease duration in seconds reported by Vault when the SecretID was issued",
+			},
+			"wrap_ttl": &schema.Schema{
+				Type:        schema.TypeString,
+				Optional:    true,
+				ForceNew:    true,
+				Description: "If set, request the SecretID as a response-wrapped token with this TTL (e.g. \"60s\") instead of storing the raw SecretID in state.",
+			},
+			"wrapping_token": &schema.Schema{
+				Type:        schema.TypeString,
+				Computed:    true,
+				Description: "Single-use cubbyhole token that can be exchanged once for the SecretID, when wrap_ttl is set",
+			},
+			"wrapping_accessor": &schema.Schema{
+				Type:        schema.TypeString,
+				Computed:    true,
+				Description: "Accessor for wrapping_token, usable to look up or revoke it without consuming it",
+			},
+			"wrapping_ttl": &schema.Schema{
+				Type:        schema.TypeInt,
+				Computed:    true,
+				Description: "TTL in seconds of wrapping_token",
+			},
+			"wrapping_creation_path": &schema.Schema{
+				Type:        schema.TypeString,
+				Computed:    true,
+				Description: "Vault path that created wrapping_token",
+			},
+			"transit_context": &schema.Schema{
+				Type:        schema.TypeString,
+				Optional:    true,
+				ForceNew:    true,
+				Description: "Context used to encrypt secret_id when the provider's transit block uses a key with derivation enabled. Pass the same value as the context argument to immutability_transit_plaintext to decrypt it again.",
+			},
 		},
 	}
 }
@@ -62,12 +128,45 @@ func approleWrite(d *schema.ResourceData, meta interface{}) error {
 
 	roleID := secretRole.Data["role_id"].(string)
 	log.Printf("[DEBUG] Got RoleID = %s", roleID)
-	secret, err := client.Logical().Write(path+"/secret-id", data)
+
+	secretIDPath := path + "/secret-id"
+	wrapTTL := d.Get("wrap_ttl").(string)
+	if wrapTTL != "" {
+		log.Printf("[DEBUG] Requesting SecretID wrapped with TTL %s", wrapTTL)
+		client.SetWrappingLookupFunc(func(operation, requestPath string) string {
+			if requestPath == secretIDPath {
+				return wrapTTL
+			}
+			return ""
+		})
+		defer client.SetWrappingLookupFunc(nil)
+	}
+
+	secret, err := client.Logical().Write(secretIDPath, data)
 
 	if err != nil || secret == nil {
 		return fmt.Errorf("Error generating SecretID from Vault: %s", err)
 	}
 
+	d.SetId(path)
+	d.Set("role_id", roleID)
+	d.Set("auth_path", client.Address()+"/v1/auth/approle/"+context.namespaceDomain+"/"+context.githubOrg+"/login")
+
+	if wrapTTL != "" {
+		if secret.WrapInfo == nil {
+			return errors.New("wrap_ttl was set but Vault did not return a wrapped response")
+		}
+		log.Printf("[DEBUG] Got wrapping_token accessor = %s", secret.WrapInfo.Accessor)
+		d.Set("secret_id", "")
+		d.Set("wrapping_token", secret.WrapInfo.Token)
+		d.Set("wrapping_accessor", secret.WrapInfo.Accessor)
+		d.Set("wrapping_ttl", secret.WrapInfo.TTL)
+		d.Set("wrapping_creation_path", secret.WrapInfo.CreationPath)
+		// The SecretID lease itself is hidden inside the wrapped
+		// response until unwrapped, so there is nothing to renew here.
+		return nil
+	}
+
 	if _, present := secret.Data["secret_id"]; !present {
 		return errors.New("secretID not found")
 	}
@@ -75,10 +174,41 @@ func approleWrite(d *schema.ResourceData, meta interface{}) error {
 	secretID := secret.Data["secret_id"].(string)
 	log.Printf("[DEBUG] Got secretID = %s", secretID)
 
-	d.SetId(path)
-	d.Set("role_id", roleID)
-	d.Set("secret_id", secretID)
-	d.Set("auth_path", client.Address()+"/v1/auth/approle/"+context.namespaceDomain+"/"+context.githubOrg+"/login")
+	transitContext := d.Get("transit_context").(string)
+	storedSecretID, err := context.transit.encryptOrPlain(secretID, transitContext)
+	if err != nil {
+		return fmt.Errorf("Error encrypting secret_id: %s", err)
+	}
+	d.Set("secret_id", storedSecretID)
+	d.Set("lease_renewable", secret.Renewable)
+	d.Set("lease_start_time", time.Now().Format(time.RFC3339))
+	d.Set("lease_max_ttl", secret.LeaseDuration)
+
+	if d.Get("auto_renew").(bool) {
+		stopOnError := d.Get("stop_on_error").(bool)
+		err = context.renewers.Watch(RenewerConfig{
+			ID:        path,
+			Client:    &client,
+			Secret:    secret,
+			Increment: d.Get("renew_increment").(int),
+			OnRenew: func(renewed *api.Secret) {
+				newSecretID, ok := renewed.Data["secret_id"].(string)
+				if !ok {
+					return
+				}
+				context.renewers.SetValue(path, newSecretID)
+			},
+			OnError: func(err error) {
+				if stopOnError {
+					context.renewers.SetError(path, err)
+				}
+			},
+		})
+		if err != nil {
+			return fmt.Errorf("Error starting SecretID renewer: %s", err)
+		}
+	}
+
 	return nil
 }
 
@@ -87,13 +217,39 @@ func approleDelete(d *schema.ResourceData, meta interface{}) error {
 	client := *context.client
 
 	path := d.Id()
-	secretID := d.Get("secret_id")
+	context.renewers.Stop(path)
+
+	wrappingToken := d.Get("wrapping_token").(string)
+	wrappingAccessor := d.Get("wrapping_accessor").(string)
+	if wrappingToken != "" {
+		_, err := client.Logical().Write("sys/wrapping/lookup", map[string]interface{}{
+			"token": wrappingToken,
+		})
+		if err == nil {
+			log.Printf("[DEBUG] wrapping_token for %s was never unwrapped, revoking accessor %s", path, wrappingAccessor)
+			if err := client.Auth().Token().RevokeAccessor(wrappingAccessor); err != nil {
+				return fmt.Errorf("Error revoking unused wrapping_token: %s", err)
+			}
+			return nil
+		}
+		// The wrapping_token was already unwrapped by whoever consumes the
+		// SecretID, so the real SecretID was never stored in state (secret_id
+		// is left "" for wrapped resources) and there is nothing here to send
+		// to secret-id/destroy. Revoking it is the consumer's responsibility.
+		log.Printf("[WARN] wrapping_token for %s was already unwrapped elsewhere; Terraform never saw the real secret_id and cannot revoke it", path)
+		return nil
+	}
+
+	secretID, err := context.transit.decryptOrPlain(d.Get("secret_id").(string), d.Get("transit_context").(string))
+	if err != nil {
+		return fmt.Errorf("Error decrypting secret_id: %s", err)
+	}
 	var data map[string]interface{}
 	data = make(map[string]interface{})
 	data["secret_id"] = secretID
 	log.Printf("[DEBUG] Revoking secret_id at %s ", path)
 	uri := path + "/secret-id/destroy"
-	_, err := client.Logical().Write(uri, data)
+	_, err = client.Logical().Write(uri, data)
 	if err != nil {
 		return fmt.Errorf("Error Revoking secret_id: %s", err)
 	}
@@ -102,6 +258,20 @@ func approleDelete(d *schema.ResourceData, meta interface{}) error {
 }
 
 func approleRead(d *schema.ResourceData, meta interface{}) error {
+	context := meta.(*ResourceContext)
+
+	if err := context.renewers.TakeError(d.Id()); err != nil && d.Get("stop_on_error").(bool) {
+		d.SetId("")
+		return fmt.Errorf("SecretID renewal failed, tainting resource: %s", err)
+	}
+
+	if newSecretID, ok := context.renewers.TakeValue(d.Id()); ok {
+		stored, err := context.transit.encryptOrPlain(newSecretID, d.Get("transit_context").(string))
+		if err != nil {
+			return fmt.Errorf("Error encrypting renewed secret_id: %s", err)
+		}
+		d.Set("secret_id", stored)
+	}
 
 	return nil
 }
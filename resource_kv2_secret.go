@@ -0,0 +1,147 @@
+package main
+
+import (
+	"fmt"
+	"log"
+
+	"github.com/hashicorp/terraform/helper/schema"
+)
+
+func kv2SecretResource() *schema.Resource {
+	return &schema.Resource{
+		Create: kv2SecretWrite,
+		Update: kv2SecretWrite,
+		Read:   kv2SecretRead,
+		Delete: kv2SecretDelete,
+
+		Schema: map[string]*schema.Schema{
+			"path": &schema.Schema{
+				Type:        schema.TypeString,
+				Required:    true,
+				ForceNew:    true,
+				Description: "Full logical path of the secret, e.g. \"secret/myapp/config\"",
+			},
+			"data": &schema.Schema{
+				Type:        schema.TypeMap,
+				Required:    true,
+				Description: "Map of values to write as the current version of the secret",
+			},
+			"cas": &schema.Schema{
+				Type:        schema.TypeInt,
+				Optional:    true,
+				Description: "Check-and-set: only write if the current version matches this value. Use 0 to require the secret not already exist.",
+			},
+			"version": &schema.Schema{
+				Type:        schema.TypeInt,
+				Computed:    true,
+				Description: "Version number created by the most recent write",
+			},
+			"created_time": &schema.Schema{
+				Type:        schema.TypeString,
+				Computed:    true,
+				Description: "Creation time of the current version",
+			},
+			"deleted_time": &schema.Schema{
+				Type:        schema.TypeString,
+				Computed:    true,
+				Description: "Deletion time of the current version, if it has been soft-deleted",
+			},
+			"destroyed": &schema.Schema{
+				Type:        schema.TypeBool,
+				Computed:    true,
+				Description: "True if the current version has been permanently destroyed",
+			},
+			"custom_metadata": &schema.Schema{
+				Type:        schema.TypeMap,
+				Computed:    true,
+				Description: "Custom metadata attached to the secret",
+			},
+		},
+	}
+}
+
+func kv2SecretWrite(d *schema.ResourceData, meta interface{}) error {
+	context := meta.(*ResourceContext)
+	client := *context.client
+
+	path := d.Get("path").(string)
+	mount, err := resolveKV2Mount(&client, path)
+	if err != nil {
+		return err
+	}
+	if !mount.IsV2() {
+		return fmt.Errorf("%s is mounted as a KV version %s engine; immutability_kv2_secret requires KV v2 (use immutability_secret instead)", path, mount.Version)
+	}
+	key := mount.Key(path)
+
+	payload := map[string]interface{}{
+		"data": d.Get("data").(map[string]interface{}),
+	}
+	if cas, ok := d.GetOkExists("cas"); ok {
+		payload["options"] = map[string]interface{}{"cas": cas.(int)}
+	}
+
+	log.Printf("[DEBUG] Writing KV v2 secret to %s", mount.dataPath(key))
+	secret, err := client.Logical().Write(mount.dataPath(key), payload)
+	if err != nil {
+		return fmt.Errorf("error writing %s: %s", path, err)
+	}
+	if secret == nil {
+		return fmt.Errorf("no response writing %s", path)
+	}
+
+	d.SetId(path)
+	kv2SetComputedFromMetadata(d, secret.Data)
+	return nil
+}
+
+func kv2SecretRead(d *schema.ResourceData, meta interface{}) error {
+	context := meta.(*ResourceContext)
+	client := *context.client
+
+	path := d.Id()
+	mount, err := resolveKV2Mount(&client, path)
+	if err != nil {
+		return err
+	}
+	key := mount.Key(path)
+
+	secret, err := client.Logical().Read(mount.dataPath(key))
+	if err != nil {
+		return fmt.Errorf("error reading %s: %s", path, err)
+	}
+	if secret == nil || secret.Data["data"] == nil {
+		log.Printf("[DEBUG] %s no longer exists in Vault", path)
+		d.SetId("")
+		return nil
+	}
+
+	if data, ok := secret.Data["data"].(map[string]interface{}); ok {
+		d.Set("data", data)
+	}
+	if metadata, ok := secret.Data["metadata"].(map[string]interface{}); ok {
+		kv2SetComputedFromMetadata(d, metadata)
+	}
+
+	return nil
+}
+
+func kv2SecretDelete(d *schema.ResourceData, meta interface{}) error {
+	context := meta.(*ResourceContext)
+	client := *context.client
+
+	path := d.Id()
+	mount, err := resolveKV2Mount(&client, path)
+	if err != nil {
+		return err
+	}
+	key := mount.Key(path)
+
+	log.Printf("[DEBUG] Deleting all versions and metadata at %s", mount.metadataPath(key))
+	_, err = client.Logical().Delete(mount.metadataPath(key))
+	if err != nil {
+		return fmt.Errorf("error deleting %s: %s", path, err)
+	}
+
+	return nil
+}
@@ -3,8 +3,10 @@ package main
 import (
 	"fmt"
 	"log"
+	"time"
 
 	"github.com/hashicorp/terraform/helper/schema"
+	"github.com/hashicorp/vault/api"
 )
 
 func pkiResource() *schema.Resource {
@@ -75,6 +77,110 @@ func pkiResource() *schema.Resource {
 				Computed:    true,
 				Description: "The revocation time",
 			},
+			"auto_renew": &schema.Schema{
+				Type:        schema.TypeBool,
+				Optional:    true,
+				Default:     false,
+				Description: "Automatically renew the certificate lease in the background for as long as the resource exists",
+			},
+			"renew_increment": &schema.Schema{
+				Type:        schema.TypeInt,
+				Optional:    true,
+				Description: "Requested number of seconds to extend the lease by on each renewal, passed to Vault as a hint",
+			},
+			"stop_on_error": &schema.Schema{
+				Type:        schema.TypeBool,
+				Optional:    true,
+				Default:     false,
+				Description: "If the background renewal fails, taint this resource instead of letting the lease silently expire",
+			},
+			"lease_renewable": &schema.Schema{
+				Type:        schema.TypeBool,
+				Computed:    true,
+				Description: "True if the certificate lease returned by Vault can be renewed",
+			},
+			"lease_start_time": &schema.Schema{
+				Type:        schema.TypeString,
+				Computed:    true,
+				Description: "Time at which the lease was issued, using the clock of the system where Terraform was running",
+			},
+			"lease_max_ttl": &schema.Schema{
+				Type:        schema.TypeInt,
+				Computed:    true,
+				Description: "Lease duration in seconds reported by Vault when the certificate was issued",
+			},
+			"csr": &schema.Schema{
+				Type:        schema.TypeString,
+				Optional:    true,
+				ForceNew:    true,
+				Description: "PEM-encoded CSR to sign instead of having Vault generate a key. Switches the request to <path>/sign/<role>.",
+			},
+			"generate_key": &schema.Schema{
+				Type:        schema.TypeBool,
+				Optional:    true,
+				ForceNew:    true,
+				Description: "Generate a private key and CSR locally and have Vault sign it, so the private key never reaches Vault. Ignored if csr is set.",
+			},
+			"key_type": &schema.Schema{
+				Type:        schema.TypeString,
+				Optional:    true,
+				ForceNew:    true,
+				Default:     "rsa",
+				Description: "Key type to generate locally when generate_key is true: \"rsa\" or \"ec\"",
+			},
+			"key_bits": &schema.Schema{
+				Type:        schema.TypeInt,
+				Optional:    true,
+				ForceNew:    true,
+				Description: "Key size in bits to generate locally when generate_key is true, e.g. 2048 for rsa or 256/384 for ec",
+			},
+			"exclude_cn_from_sans": &schema.Schema{
+				Type:        schema.TypeBool,
+				Optional:    true,
+				ForceNew:    true,
+				Description: "Do not add common_name to the certificate's subject alternative names",
+			},
+			"uri_sans": &schema.Schema{
+				Type:        schema.TypeString,
+				Optional:    true,
+				ForceNew:    true,
+				Description: "URI Subject Alt Names, comma delimited",
+			},
+			"other_sans": &schema.Schema{
+				Type:        schema.TypeString,
+				Optional:    true,
+				ForceNew:    true,
+				Description: "Custom OID Subject Alt Names, comma delimited, in <oid>;<type>:<value> form",
+			},
+			"not_after": &schema.Schema{
+				Type:        schema.TypeString,
+				Optional:    true,
+				ForceNew:    true,
+				Description: "Certificate expiration as an RFC3339 timestamp, as an alternative to ttl",
+			},
+			"format": &schema.Schema{
+				Type:        schema.TypeString,
+				Optional:    true,
+				ForceNew:    true,
+				Description: "Format for certificate/issuing_ca: \"pem\", \"der\", or \"pem_bundle\"",
+			},
+			"private_key_pem": &schema.Schema{
+				Type:        schema.TypeString,
+				Computed:    true,
+				Description: "Private key generated locally when generate_key is true. Empty when Vault generated the key instead.",
+			},
+			"tidy_on_destroy": &schema.Schema{
+				Type:        schema.TypeBool,
+				Optional:    true,
+				Default:     false,
+				Description: "Schedule a tidy operation on the PKI mount after this certificate is revoked",
+			},
+			"transit_context": &schema.Schema{
+				Type:        schema.TypeString,
+				Optional:    true,
+				ForceNew:    true,
+				Description: "Context used to encrypt certificate/private_key when the provider's transit block uses a key with derivation enabled. Pass the same value as the context argument to immutability_transit_plaintext to decrypt it again.",
+			},
 		},
 	}
 }
@@ -88,8 +194,17 @@ func pkiWrite(d *schema.ResourceData, meta interface{}) error {
 	altNames := d.Get("alt_names").(string)
 	ipSANS := d.Get("ip_sans").(string)
 	ttl := d.Get("ttl").(string)
+	csrPEM := d.Get("csr").(string)
+
+	var localPrivateKeyPEM string
+	if csrPEM == "" && d.Get("generate_key").(bool) {
+		var err error
+		csrPEM, localPrivateKeyPEM, err = generateCSR(commonName, d.Get("key_type").(string), d.Get("key_bits").(int))
+		if err != nil {
+			return fmt.Errorf("error generating local key and CSR: %s", err)
+		}
+	}
 
-	log.Printf("[DEBUG] Issuing %s certificate", commonName)
 	var data map[string]interface{}
 	data = make(map[string]interface{})
 	data["common_name"] = commonName
@@ -103,7 +218,32 @@ func pkiWrite(d *schema.ResourceData, meta interface{}) error {
 	if ttl != "" {
 		data["ttl"] = ttl
 	}
-	uri := path + "/issue/" + context.githubOrg
+	if uriSANS := d.Get("uri_sans").(string); uriSANS != "" {
+		data["uri_sans"] = uriSANS
+	}
+	if otherSANS := d.Get("other_sans").(string); otherSANS != "" {
+		data["other_sans"] = otherSANS
+	}
+	if notAfter := d.Get("not_after").(string); notAfter != "" {
+		data["not_after"] = notAfter
+	}
+	if format := d.Get("format").(string); format != "" {
+		data["format"] = format
+	}
+	if d.Get("exclude_cn_from_sans").(bool) {
+		data["exclude_cn_from_sans"] = true
+	}
+
+	var uri string
+	if csrPEM != "" {
+		data["csr"] = csrPEM
+		uri = path + "/sign/" + context.githubOrg
+		log.Printf("[DEBUG] Signing CSR for %s certificate", commonName)
+	} else {
+		uri = path + "/issue/" + context.githubOrg
+		log.Printf("[DEBUG] Issuing %s certificate", commonName)
+	}
+
 	secret, err := client.Logical().Write(uri, data)
 
 	if err != nil {
@@ -112,11 +252,65 @@ func pkiWrite(d *schema.ResourceData, meta interface{}) error {
 	log.Print(secret.Data)
 	id := secret.Data["serial_number"].(string)
 	d.SetId(id)
-	d.Set("certificate", secret.Data["certificate"])
-	d.Set("private_key", secret.Data["private_key"])
+
+	transitContext := d.Get("transit_context").(string)
+
+	certificate, _ := secret.Data["certificate"].(string)
+	storedCertificate, err := context.transit.encryptOrPlain(certificate, transitContext)
+	if err != nil {
+		return fmt.Errorf("error encrypting certificate: %s", err)
+	}
+	d.Set("certificate", storedCertificate)
 	d.Set("issuing_ca", secret.Data["issuing_ca"])
-	d.Set("private_key_type", secret.Data["private_key_type"])
 	d.Set("serial_number", secret.Data["serial_number"])
+	d.Set("lease_renewable", secret.Renewable)
+	d.Set("lease_start_time", time.Now().Format(time.RFC3339))
+	d.Set("lease_max_ttl", secret.LeaseDuration)
+
+	if localPrivateKeyPEM != "" {
+		// The key was generated here and only the CSR was sent to Vault,
+		// so Vault never saw and never returns a private key.
+		storedPrivateKeyPEM, err := context.transit.encryptOrPlain(localPrivateKeyPEM, transitContext)
+		if err != nil {
+			return fmt.Errorf("error encrypting private_key_pem: %s", err)
+		}
+		d.Set("private_key", "")
+		d.Set("private_key_pem", storedPrivateKeyPEM)
+		d.Set("private_key_type", d.Get("key_type").(string))
+	} else {
+		privateKey, _ := secret.Data["private_key"].(string)
+		storedPrivateKey, err := context.transit.encryptOrPlain(privateKey, transitContext)
+		if err != nil {
+			return fmt.Errorf("error encrypting private_key: %s", err)
+		}
+		d.Set("private_key", storedPrivateKey)
+		d.Set("private_key_type", secret.Data["private_key_type"])
+	}
+
+	if d.Get("auto_renew").(bool) {
+		stopOnError := d.Get("stop_on_error").(bool)
+		err = context.renewers.Watch(RenewerConfig{
+			ID:        id,
+			Client:    &client,
+			Secret:    secret,
+			Increment: d.Get("renew_increment").(int),
+			OnRenew: func(renewed *api.Secret) {
+				cert, ok := renewed.Data["certificate"].(string)
+				if !ok {
+					return
+				}
+				context.renewers.SetValue(id, cert)
+			},
+			OnError: func(err error) {
+				if stopOnError {
+					context.renewers.SetError(id, err)
+				}
+			},
+		})
+		if err != nil {
+			return fmt.Errorf("error starting certificate renewer: %s", err)
+		}
+	}
 
 	return nil
 }
@@ -126,6 +320,8 @@ func pkiDelete(d *schema.ResourceData, meta interface{}) error {
 	client := *context.client
 
 	id := d.Id()
+	context.renewers.Stop(id)
+
 	path := d.Get("path").(string)
 	var data map[string]interface{}
 	data = make(map[string]interface{})
@@ -138,10 +334,36 @@ func pkiDelete(d *schema.ResourceData, meta interface{}) error {
 	}
 
 	d.Set("revocation_time", secret.Data["revocation_time"])
+
+	if d.Get("tidy_on_destroy").(bool) {
+		log.Printf("[DEBUG] Scheduling tidy at %s/tidy", path)
+		_, err := client.Logical().Write(path+"/tidy", map[string]interface{}{
+			"tidy_cert_store":    true,
+			"tidy_revoked_certs": true,
+		})
+		if err != nil {
+			log.Printf("[ERROR] Failed to schedule tidy at %s/tidy: %s", path, err)
+		}
+	}
+
 	return nil
 }
 
 func pkiRead(d *schema.ResourceData, meta interface{}) error {
+	context := meta.(*ResourceContext)
+
+	if err := context.renewers.TakeError(d.Id()); err != nil && d.Get("stop_on_error").(bool) {
+		d.SetId("")
+		return fmt.Errorf("certificate renewal failed, tainting resource: %s", err)
+	}
+
+	if cert, ok := context.renewers.TakeValue(d.Id()); ok {
+		stored, err := context.transit.encryptOrPlain(cert, d.Get("transit_context").(string))
+		if err != nil {
+			return fmt.Errorf("error encrypting renewed certificate: %s", err)
+		}
+		d.Set("certificate", stored)
+	}
 
 	return nil
 }
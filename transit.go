@@ -0,0 +1,234 @@
+package main
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"encoding/base64"
+	"fmt"
+	"strings"
+
+	"github.com/hashicorp/vault/api"
+)
+
+// TransitConfig is the provider-level `transit { mount = "..."; key = "..." }`
+// block. When set, computed secret fields are encrypted with Vault Transit
+// before being written to Terraform state. Refresh does not decrypt them:
+// state keeps the ciphertext, and the plaintext is only recovered through
+// Decrypt (immutability_transit_plaintext, and approleDelete for secret_id).
+type TransitConfig struct {
+	Client  *api.Client
+	Mount   string
+	Key     string
+	Datakey bool
+}
+
+const (
+	transitCiphertextPrefix = "vault:v"
+	datakeyCiphertextPrefix = "datakey:"
+)
+
+// IsEncrypted reports whether value already looks like ciphertext this
+// package produced, so Decrypt is safe to call on values that might
+// predate transit being configured.
+func IsEncrypted(value string) bool {
+	return strings.HasPrefix(value, transitCiphertextPrefix) || strings.HasPrefix(value, datakeyCiphertextPrefix)
+}
+
+// Encrypt wraps value with Transit, using datakey envelope encryption
+// instead of a direct encrypt call when t.Datakey is set.
+func (t *TransitConfig) Encrypt(value string, transitContext string) (string, error) {
+	if t.Datakey {
+		return t.datakeyEncrypt(value, transitContext)
+	}
+	return t.transitEncrypt(value, transitContext)
+}
+
+// Decrypt reverses Encrypt, dispatching on the ciphertext's own prefix
+// rather than t.Datakey so state written under one mode still decrypts
+// correctly after the provider config switches to the other.
+func (t *TransitConfig) Decrypt(value string, transitContext string) (string, error) {
+	if strings.HasPrefix(value, datakeyCiphertextPrefix) {
+		return t.datakeyDecrypt(value, transitContext)
+	}
+	return t.transitDecrypt(value, transitContext)
+}
+
+// encryptOrPlain calls Encrypt unless t is nil or value is empty, so
+// resources can encrypt computed fields unconditionally and get back the
+// original value when no transit block is configured.
+func (t *TransitConfig) encryptOrPlain(value string, transitContext string) (string, error) {
+	if t == nil || value == "" {
+		return value, nil
+	}
+	return t.Encrypt(value, transitContext)
+}
+
+// decryptOrPlain is encryptOrPlain's counterpart: it is a no-op unless t
+// is configured and value actually looks like ciphertext.
+func (t *TransitConfig) decryptOrPlain(value string, transitContext string) (string, error) {
+	if t == nil || value == "" || !IsEncrypted(value) {
+		return value, nil
+	}
+	return t.Decrypt(value, transitContext)
+}
+
+func (t *TransitConfig) transitEncrypt(value string, transitContext string) (string, error) {
+	data := map[string]interface{}{
+		"plaintext": base64.StdEncoding.EncodeToString([]byte(value)),
+	}
+	if transitContext != "" {
+		data["context"] = base64.StdEncoding.EncodeToString([]byte(transitContext))
+	}
+
+	secret, err := t.Client.Logical().Write(t.Mount+"/encrypt/"+t.Key, data)
+	if err != nil {
+		return "", fmt.Errorf("error encrypting with transit: %s", err)
+	}
+	ciphertext, ok := secret.Data["ciphertext"].(string)
+	if !ok {
+		return "", fmt.Errorf("transit encrypt response did not contain a ciphertext")
+	}
+	return ciphertext, nil
+}
+
+func (t *TransitConfig) transitDecrypt(value string, transitContext string) (string, error) {
+	plaintext, err := t.transitDecryptRaw(value, transitContext)
+	if err != nil {
+		return "", err
+	}
+	return string(plaintext), nil
+}
+
+func (t *TransitConfig) transitDecryptRaw(ciphertext string, transitContext string) ([]byte, error) {
+	data := map[string]interface{}{
+		"ciphertext": ciphertext,
+	}
+	if transitContext != "" {
+		data["context"] = base64.StdEncoding.EncodeToString([]byte(transitContext))
+	}
+
+	secret, err := t.Client.Logical().Write(t.Mount+"/decrypt/"+t.Key, data)
+	if err != nil {
+		return nil, fmt.Errorf("error decrypting with transit: %s", err)
+	}
+	plaintextB64, ok := secret.Data["plaintext"].(string)
+	if !ok {
+		return nil, fmt.Errorf("transit decrypt response did not contain plaintext")
+	}
+	return base64.StdEncoding.DecodeString(plaintextB64)
+}
+
+// datakeyEncrypt wraps value using envelope encryption: a fresh AES-256-GCM
+// data key is requested from <mount>/datakey/plaintext/<key>, used once to
+// seal value locally, and discarded. Only the Transit-wrapped data key,
+// the GCM nonce, and the sealed payload are kept, so payloads too large
+// for a direct /encrypt call never have to touch Transit themselves.
+func (t *TransitConfig) datakeyEncrypt(value string, transitContext string) (string, error) {
+	data := map[string]interface{}{}
+	if transitContext != "" {
+		data["context"] = base64.StdEncoding.EncodeToString([]byte(transitContext))
+	}
+
+	secret, err := t.Client.Logical().Write(t.Mount+"/datakey/plaintext/"+t.Key, data)
+	if err != nil {
+		return "", fmt.Errorf("error requesting transit data key: %s", err)
+	}
+	plaintextKeyB64, _ := secret.Data["plaintext"].(string)
+	wrappedKey, _ := secret.Data["ciphertext"].(string)
+	if plaintextKeyB64 == "" || wrappedKey == "" {
+		return "", fmt.Errorf("transit datakey response missing plaintext or ciphertext")
+	}
+
+	plaintextKey, err := base64.StdEncoding.DecodeString(plaintextKeyB64)
+	if err != nil {
+		return "", fmt.Errorf("error base64-decoding data key: %s", err)
+	}
+
+	return sealEnvelope(plaintextKey, wrappedKey, value)
+}
+
+func (t *TransitConfig) datakeyDecrypt(value string, transitContext string) (string, error) {
+	wrappedKey, nonce, sealed, err := parseEnvelope(value)
+	if err != nil {
+		return "", err
+	}
+
+	plaintextKey, err := t.transitDecryptRaw(wrappedKey, transitContext)
+	if err != nil {
+		return "", err
+	}
+
+	return openEnvelope(plaintextKey, nonce, sealed)
+}
+
+// sealEnvelope AES-256-GCM-encrypts value under key and frames the result
+// as "datakey:<wrapped key>:<nonce>:<ciphertext>", base64-encoding the
+// binary parts. wrappedKey is carried through unencrypted here since it is
+// already Transit ciphertext - only transitDecryptRaw can open it.
+func sealEnvelope(key []byte, wrappedKey string, value string) (string, error) {
+	gcm, err := newAESGCM(key)
+	if err != nil {
+		return "", err
+	}
+	nonce := make([]byte, gcm.NonceSize())
+	if _, err := rand.Read(nonce); err != nil {
+		return "", fmt.Errorf("error generating nonce: %s", err)
+	}
+	sealed := gcm.Seal(nil, nonce, []byte(value), nil)
+
+	return datakeyCiphertextPrefix + strings.Join([]string{
+		wrappedKey,
+		base64.StdEncoding.EncodeToString(nonce),
+		base64.StdEncoding.EncodeToString(sealed),
+	}, ":"), nil
+}
+
+// parseEnvelope reverses the framing sealEnvelope applies, without
+// touching the AES layer, so the wrapped key can be sent to Transit for
+// unwrapping before openEnvelope is called. The wrapped key is Transit
+// ciphertext and itself contains colons, so the nonce and payload are taken
+// from the last two fields and everything before them is the wrapped key.
+func parseEnvelope(value string) (wrappedKey string, nonce []byte, sealed []byte, err error) {
+	parts := strings.Split(strings.TrimPrefix(value, datakeyCiphertextPrefix), ":")
+	if len(parts) < 3 {
+		return "", nil, nil, fmt.Errorf("malformed datakey ciphertext")
+	}
+	n := len(parts)
+
+	nonce, err = base64.StdEncoding.DecodeString(parts[n-2])
+	if err != nil {
+		return "", nil, nil, fmt.Errorf("error base64-decoding nonce: %s", err)
+	}
+	sealed, err = base64.StdEncoding.DecodeString(parts[n-1])
+	if err != nil {
+		return "", nil, nil, fmt.Errorf("error base64-decoding ciphertext: %s", err)
+	}
+	return strings.Join(parts[:n-2], ":"), nonce, sealed, nil
+}
+
+// openEnvelope reverses sealEnvelope's AES-256-GCM layer given the
+// already-unwrapped plaintext key.
+func openEnvelope(key []byte, nonce []byte, sealed []byte) (string, error) {
+	gcm, err := newAESGCM(key)
+	if err != nil {
+		return "", err
+	}
+	plaintext, err := gcm.Open(nil, nonce, sealed, nil)
+	if err != nil {
+		return "", fmt.Errorf("error decrypting datakey payload: %s", err)
+	}
+	return string(plaintext), nil
+}
+
+func newAESGCM(key []byte) (cipher.AEAD, error) {
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, fmt.Errorf("error initializing AES cipher: %s", err)
+	}
+	gcm, err := cipher.NewGCM(block)
+	if err != nil {
+		return nil, fmt.Errorf("error initializing AES-GCM: %s", err)
+	}
+	return gcm, nil
+}
@@ -0,0 +1,69 @@
+package main
+
+import "testing"
+
+func TestSealOpenEnvelopeRoundTrip(t *testing.T) {
+	key := make([]byte, 32)
+	for i := range key {
+		key[i] = byte(i)
+	}
+
+	envelope, err := sealEnvelope(key, "wrapped-key-placeholder", "super secret value")
+	if err != nil {
+		t.Fatalf("sealEnvelope: %s", err)
+	}
+
+	wrappedKey, nonce, sealed, err := parseEnvelope(envelope)
+	if err != nil {
+		t.Fatalf("parseEnvelope: %s", err)
+	}
+	if wrappedKey != "wrapped-key-placeholder" {
+		t.Fatalf("wrappedKey = %q, want %q", wrappedKey, "wrapped-key-placeholder")
+	}
+
+	plaintext, err := openEnvelope(key, nonce, sealed)
+	if err != nil {
+		t.Fatalf("openEnvelope: %s", err)
+	}
+	if plaintext != "super secret value" {
+		t.Fatalf("plaintext = %q, want %q", plaintext, "super secret value")
+	}
+}
+
+func TestOpenEnvelopeWrongKeyFails(t *testing.T) {
+	key := make([]byte, 32)
+	wrongKey := make([]byte, 32)
+	wrongKey[0] = 1
+
+	envelope, err := sealEnvelope(key, "wrapped", "value")
+	if err != nil {
+		t.Fatalf("sealEnvelope: %s", err)
+	}
+	_, nonce, sealed, err := parseEnvelope(envelope)
+	if err != nil {
+		t.Fatalf("parseEnvelope: %s", err)
+	}
+	if _, err := openEnvelope(wrongKey, nonce, sealed); err == nil {
+		t.Fatal("expected an error decrypting with the wrong key")
+	}
+}
+
+func TestParseEnvelopeMalformed(t *testing.T) {
+	if _, _, _, err := parseEnvelope(datakeyCiphertextPrefix + "only-one-part"); err == nil {
+		t.Fatal("expected an error for a malformed envelope")
+	}
+}
+
+func TestIsEncrypted(t *testing.T) {
+	cases := map[string]bool{
+		"vault:v1:abc123":       true,
+		"datakey:wrap:nonce:ct": true,
+		"plain-value":           false,
+		"":                      false,
+	}
+	for value, want := range cases {
+		if got := IsEncrypted(value); got != want {
+			t.Errorf("IsEncrypted(%q) = %v, want %v", value, got, want)
+		}
+	}
+}